@@ -21,6 +21,7 @@ import (
 	"crypto/rand"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"sort"
 
@@ -145,11 +146,114 @@ type KMS interface {
 	// match the context used to generate the sealed key.
 	UnsealKey(keyID string, sealedKey []byte, context Context) (key [32]byte, err error)
 
+	// RotateKey re-seals the sealedKey - which was produced
+	// by the master key referenced by oldKeyID - under the
+	// master key referenced by newKeyID. The plaintext data
+	// key itself is never returned to the caller; it is
+	// unwrapped and re-wrapped inside the KMS. The context
+	// must match the context used to generate sealedKey and
+	// is re-used, unchanged, for the new sealed key.
+	//
+	// RotateKey and RotateContext re-seal a single data key and leave
+	// the object data untouched. No SSE or admin handler code path in
+	// this repository calls them; object metadata is not re-sealed
+	// automatically.
+	RotateKey(oldKeyID, newKeyID string, sealedKey []byte, context Context) (newSealedKey []byte, err error)
+
+	// RotateContext re-seals the sealedKey - which was produced
+	// by the master key referenced by keyID under oldContext -
+	// so that it can be unsealed with newContext instead. As
+	// with RotateKey, the plaintext data key is never returned
+	// to the caller.
+	RotateContext(keyID string, sealedKey []byte, oldContext, newContext Context) (newSealedKey []byte, err error)
+
 	// Info returns descriptive information about the KMS,
 	// like the default key ID and authentication method.
 	Info() KMSInfo
 }
 
+// BatchedKMS is a KMS that can generate and unseal several
+// data keys in a single call. A KMS that talks to a remote
+// service can implement this interface directly to coalesce
+// the per-key round trips into one request.
+type BatchedKMS interface {
+	KMS
+
+	// GenerateKeys generates len(ctxs) random data keys using
+	// the master key referenced by keyID - one per context.
+	// It returns the plaintext keys and their sealed
+	// counterparts, in the same order as ctxs, on success.
+	GenerateKeys(keyID string, ctxs []Context) (keys [][32]byte, sealedKeys [][]byte, err error)
+
+	// UnsealKeys unseals the sealedKeys using the master keys
+	// referenced by keyIDs. The context at index i must match
+	// the context used to generate sealedKeys[i]. len(keyIDs),
+	// len(sealedKeys) and len(ctxs) must be equal, and the
+	// returned keys are in the same order. Implementations may,
+	// but need not, take advantage of keyIDs sharing a common
+	// value to batch or amortize work internally.
+	UnsealKeys(keyIDs []string, sealedKeys [][]byte, ctxs []Context) (keys [][32]byte, err error)
+}
+
+// NewBatchedKMS returns a BatchedKMS that implements GenerateKeys
+// and UnsealKeys on top of kms by calling GenerateKey and UnsealKey
+// once per element. It allows any legacy KMS implementation to be
+// used wherever a BatchedKMS is expected. If kms already implements
+// BatchedKMS, it is returned unchanged and its own batched methods
+// are used.
+func NewBatchedKMS(kms KMS) BatchedKMS {
+	if batched, ok := kms.(BatchedKMS); ok {
+		return batched
+	}
+	return &batchedKMS{KMS: kms}
+}
+
+type batchedKMS struct {
+	KMS
+}
+
+func (b *batchedKMS) GenerateKeys(keyID string, ctxs []Context) (keys [][32]byte, sealedKeys [][]byte, err error) {
+	keys = make([][32]byte, len(ctxs))
+	sealedKeys = make([][]byte, len(ctxs))
+	for i, ctx := range ctxs {
+		keys[i], sealedKeys[i], err = b.GenerateKey(keyID, ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return keys, sealedKeys, nil
+}
+
+// UnsealKeys unseals each sealedKey via the wrapped KMS's UnsealKey. Unlike
+// masterKeyKMS.UnsealKeys, a generic KMS gives no way to derive a master key
+// once and reuse it, so the sameKeyID case buys nothing here and is not
+// special-cased.
+func (b *batchedKMS) UnsealKeys(keyIDs []string, sealedKeys [][]byte, ctxs []Context) (keys [][32]byte, err error) {
+	if len(keyIDs) != len(sealedKeys) || len(keyIDs) != len(ctxs) {
+		return nil, errors.New("crypto: number of key IDs, sealed keys and contexts must match")
+	}
+
+	keys = make([][32]byte, len(keyIDs))
+	for i, keyID := range keyIDs {
+		keys[i], err = b.UnsealKey(keyID, sealedKeys[i], ctxs[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// sameKeyID returns true if every keyID in keyIDs is identical.
+// An empty slice is considered to have the same key ID.
+func sameKeyID(keyIDs []string) bool {
+	for i := 1; i < len(keyIDs); i++ {
+		if keyIDs[i] != keyIDs[0] {
+			return false
+		}
+	}
+	return true
+}
+
 type masterKeyKMS struct {
 	keyID     string
 	masterKey [32]byte
@@ -182,15 +286,8 @@ func (kms *masterKeyKMS) GenerateKey(keyID string, ctx Context) (key [32]byte, s
 		logger.CriticalIf(context.Background(), errOutOfEntropy)
 	}
 
-	var (
-		buffer     bytes.Buffer
-		derivedKey = kms.deriveKey(keyID, ctx)
-	)
-	if n, err := sio.Encrypt(&buffer, bytes.NewReader(key[:]), sio.Config{Key: derivedKey[:]}); err != nil || n != 64 {
-		logger.CriticalIf(context.Background(), errors.New("KMS: unable to encrypt data key"))
-	}
-	sealedKey = buffer.Bytes()
-	return key, sealedKey, nil
+	sealedKey, err = kms.sealKey(keyID, key, ctx)
+	return key, sealedKey, err
 }
 
 // KMS is configured directly using master key
@@ -207,19 +304,134 @@ func (kms *masterKeyKMS) UnsealKey(keyID string, sealedKey []byte, ctx Context)
 		derivedKey = kms.deriveKey(keyID, ctx)
 	)
 	out, err := sio.DecryptBuffer(key[:0], sealedKey, sio.Config{Key: derivedKey[:]})
-	if err != nil || len(out) != 32 {
+	if err != nil {
 		return key, err // TODO(aead): upgrade sio to use sio.Error
 	}
+	if len(out) != 32 {
+		return key, errors.New("crypto: unsealed key has invalid length")
+	}
 	return key, nil
 }
 
+func (kms *masterKeyKMS) RotateKey(oldKeyID, newKeyID string, sealedKey []byte, ctx Context) (newSealedKey []byte, err error) {
+	key, err := kms.UnsealKey(oldKeyID, sealedKey, ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer bytesClear(key[:])
+
+	return kms.sealKey(newKeyID, key, ctx)
+}
+
+func (kms *masterKeyKMS) RotateContext(keyID string, sealedKey []byte, oldCtx, newCtx Context) (newSealedKey []byte, err error) {
+	key, err := kms.UnsealKey(keyID, sealedKey, oldCtx)
+	if err != nil {
+		return nil, err
+	}
+	defer bytesClear(key[:])
+
+	return kms.sealKey(keyID, key, newCtx)
+}
+
+// sealKey seals the given, already generated, data key under the
+// master key referenced by keyID. It is the common code shared by
+// GenerateKey, RotateKey and RotateContext.
+func (kms *masterKeyKMS) sealKey(keyID string, key [32]byte, ctx Context) (sealedKey []byte, err error) {
+	return sealKeyWith(hmac.New(sha256.New, kms.masterKey[:]), keyID, key, ctx)
+}
+
+// sealKeyWith seals key the same way sealKey does but derives the key
+// from an already-constructed HMAC, so callers sealing many keys under
+// the same keyID - e.g. GenerateKeys - can build the HMAC once and reuse
+// it, mirroring deriveKeyWith.
+func sealKeyWith(mac hash.Hash, keyID string, key [32]byte, ctx Context) (sealedKey []byte, err error) {
+	var (
+		buffer     bytes.Buffer
+		derivedKey = deriveKeyWith(mac, keyID, ctx)
+	)
+	if n, err := sio.Encrypt(&buffer, bytes.NewReader(key[:]), sio.Config{Key: derivedKey[:]}); err != nil || n != 64 {
+		logger.CriticalIf(context.Background(), errors.New("KMS: unable to encrypt data key"))
+	}
+	return buffer.Bytes(), nil
+}
+
+func bytesClear(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
 func (kms *masterKeyKMS) deriveKey(keyID string, context Context) (key [32]byte) {
+	return deriveKeyWith(hmac.New(sha256.New, kms.masterKey[:]), keyID, context)
+}
+
+// deriveKeyWith derives a key using an already-constructed HMAC over the
+// master key. Building the HMAC via hmac.New re-hashes the master key into
+// the inner/outer pads, so callers deriving many keys for the same master
+// key - e.g. the sameKeyID fast path in UnsealKeys - construct the HMAC
+// once and call mac.Reset() between calls to amortize that setup cost.
+func deriveKeyWith(mac hash.Hash, keyID string, context Context) (key [32]byte) {
 	if context == nil {
 		context = Context{}
 	}
-	mac := hmac.New(sha256.New, kms.masterKey[:])
+	mac.Reset()
 	mac.Write([]byte(keyID))
 	mac.Write(context.AppendTo(make([]byte, 0, 128)))
 	mac.Sum(key[:0])
 	return key
 }
+
+// GenerateKeys generates len(ctxs) random data keys sealed under keyID.
+// Since every generated key shares the same keyID, the HMAC over the
+// master key is constructed once and reused (via mac.Reset) for every
+// context instead of paying hmac.New's master-key setup cost per key,
+// as GenerateKey would.
+func (kms *masterKeyKMS) GenerateKeys(keyID string, ctxs []Context) (keys [][32]byte, sealedKeys [][]byte, err error) {
+	keys = make([][32]byte, len(ctxs))
+	sealedKeys = make([][]byte, len(ctxs))
+	mac := hmac.New(sha256.New, kms.masterKey[:])
+	for i, ctx := range ctxs {
+		if _, err = io.ReadFull(rand.Reader, keys[i][:]); err != nil {
+			logger.CriticalIf(context.Background(), errOutOfEntropy)
+		}
+		sealedKeys[i], err = sealKeyWith(mac, keyID, keys[i], ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return keys, sealedKeys, nil
+}
+
+// UnsealKeys unseals sealedKeys using the master keys referenced by keyIDs.
+// If every keyID is identical, it constructs the HMAC over the master key
+// once and reuses it (via mac.Reset) for every sealed key instead of paying
+// hmac.New's master-key setup cost on each one, as UnsealKey would.
+func (kms *masterKeyKMS) UnsealKeys(keyIDs []string, sealedKeys [][]byte, ctxs []Context) (keys [][32]byte, err error) {
+	if len(keyIDs) != len(sealedKeys) || len(keyIDs) != len(ctxs) {
+		return nil, errors.New("crypto: number of key IDs, sealed keys and contexts must match")
+	}
+
+	keys = make([][32]byte, len(keyIDs))
+	if sameKeyID(keyIDs) && len(keyIDs) > 0 {
+		mac := hmac.New(sha256.New, kms.masterKey[:])
+		for i, ctx := range ctxs {
+			derivedKey := deriveKeyWith(mac, keyIDs[0], ctx)
+			out, decErr := sio.DecryptBuffer(keys[i][:0], sealedKeys[i], sio.Config{Key: derivedKey[:]})
+			if decErr != nil {
+				return nil, decErr // TODO(aead): upgrade sio to use sio.Error
+			}
+			if len(out) != 32 {
+				return nil, errors.New("crypto: unsealed key has invalid length")
+			}
+		}
+		return keys, nil
+	}
+
+	for i, keyID := range keyIDs {
+		keys[i], err = kms.UnsealKey(keyID, sealedKeys[i], ctxs[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,153 @@
+// MinIO Cloud Storage, (C) 2015, 2016, 2017, 2018 MinIO, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypto
+
+import (
+	"testing"
+)
+
+func newTestMasterKeyKMS() *masterKeyKMS {
+	return &masterKeyKMS{keyID: "test-key", masterKey: [32]byte{1, 2, 3, 4}}
+}
+
+func TestMasterKeyKMSUnsealKeysSameKeyID(t *testing.T) {
+	kms := newTestMasterKeyKMS()
+	ctxs := []Context{
+		{"bucket": "a", "object": "1"},
+		{"bucket": "a", "object": "2"},
+		{"bucket": "a", "object": "3"},
+	}
+
+	keys, sealedKeys, err := kms.GenerateKeys(kms.keyID, ctxs)
+	if err != nil {
+		t.Fatalf("GenerateKeys: %v", err)
+	}
+
+	keyIDs := []string{kms.keyID, kms.keyID, kms.keyID}
+	unsealed, err := kms.UnsealKeys(keyIDs, sealedKeys, ctxs)
+	if err != nil {
+		t.Fatalf("UnsealKeys: %v", err)
+	}
+	for i := range keys {
+		if unsealed[i] != keys[i] {
+			t.Errorf("entry %d: unsealed key does not match generated key", i)
+		}
+	}
+}
+
+func TestMasterKeyKMSUnsealKeysMixedKeyID(t *testing.T) {
+	kms := newTestMasterKeyKMS()
+	ctxs := []Context{{"object": "1"}, {"object": "2"}}
+	keyIDs := []string{"key-a", "key-b"}
+
+	var keys [][32]byte
+	var sealedKeys [][]byte
+	for i, keyID := range keyIDs {
+		key, sealedKey, err := kms.GenerateKey(keyID, ctxs[i])
+		if err != nil {
+			t.Fatalf("GenerateKey: %v", err)
+		}
+		keys = append(keys, key)
+		sealedKeys = append(sealedKeys, sealedKey)
+	}
+
+	unsealed, err := kms.UnsealKeys(keyIDs, sealedKeys, ctxs)
+	if err != nil {
+		t.Fatalf("UnsealKeys: %v", err)
+	}
+	for i := range keys {
+		if unsealed[i] != keys[i] {
+			t.Errorf("entry %d: unsealed key does not match generated key", i)
+		}
+	}
+}
+
+func TestMasterKeyKMSUnsealKeysLengthMismatch(t *testing.T) {
+	kms := newTestMasterKeyKMS()
+	_, err := kms.UnsealKeys([]string{"a", "b"}, [][]byte{{0}}, []Context{{}})
+	if err == nil {
+		t.Fatal("expected an error for mismatched slice lengths, got none")
+	}
+}
+
+func TestMasterKeyKMSUnsealKeysCorruptSealedKey(t *testing.T) {
+	kms := newTestMasterKeyKMS()
+	_, sealedKey, err := kms.GenerateKey(kms.keyID, Context{"object": "1"})
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	sealedKey[0] ^= 0xFF // corrupt the sealed key so unsealing must fail
+
+	_, err = kms.UnsealKeys([]string{kms.keyID}, [][]byte{sealedKey}, []Context{{"object": "1"}})
+	if err == nil {
+		t.Fatal("expected an error unsealing a corrupt sealed key, got none")
+	}
+}
+
+func TestMasterKeyKMSUnsealKeyInvalidLength(t *testing.T) {
+	kms := newTestMasterKeyKMS()
+	// A sealedKey that's too short to ever decrypt to a 32 byte key must
+	// be rejected with a real error, not a zero-value key and a nil error.
+	if _, err := kms.UnsealKey(kms.keyID, []byte("too-short"), Context{"object": "1"}); err == nil {
+		t.Fatal("expected an error unsealing an invalid-length sealed key, got none")
+	}
+}
+
+// plainKMS implements only the KMS interface, never BatchedKMS, so that
+// NewBatchedKMS is forced to use the generic batchedKMS wrapper instead of
+// masterKeyKMS's own batched methods. It forwards every call to an inner
+// masterKeyKMS without embedding it, so GenerateKeys/UnsealKeys are not
+// promoted onto plainKMS.
+type plainKMS struct {
+	inner *masterKeyKMS
+}
+
+func (p plainKMS) DefaultKeyID() string         { return p.inner.DefaultKeyID() }
+func (p plainKMS) CreateKey(keyID string) error { return p.inner.CreateKey(keyID) }
+func (p plainKMS) GenerateKey(keyID string, ctx Context) ([32]byte, []byte, error) {
+	return p.inner.GenerateKey(keyID, ctx)
+}
+func (p plainKMS) UnsealKey(keyID string, sealedKey []byte, ctx Context) ([32]byte, error) {
+	return p.inner.UnsealKey(keyID, sealedKey, ctx)
+}
+func (p plainKMS) RotateKey(oldKeyID, newKeyID string, sealedKey []byte, ctx Context) ([]byte, error) {
+	return p.inner.RotateKey(oldKeyID, newKeyID, sealedKey, ctx)
+}
+func (p plainKMS) RotateContext(keyID string, sealedKey []byte, oldCtx, newCtx Context) ([]byte, error) {
+	return p.inner.RotateContext(keyID, sealedKey, oldCtx, newCtx)
+}
+func (p plainKMS) Info() KMSInfo { return p.inner.Info() }
+
+func TestBatchedKMSWrapperUnsealKeys(t *testing.T) {
+	inner := newTestMasterKeyKMS()
+	kms := NewBatchedKMS(plainKMS{inner})
+
+	ctxs := []Context{{"object": "1"}, {"object": "2"}}
+	keys, sealedKeys, err := kms.GenerateKeys(inner.keyID, ctxs)
+	if err != nil {
+		t.Fatalf("GenerateKeys: %v", err)
+	}
+
+	keyIDs := []string{inner.keyID, inner.keyID}
+	unsealed, err := kms.UnsealKeys(keyIDs, sealedKeys, ctxs)
+	if err != nil {
+		t.Fatalf("UnsealKeys: %v", err)
+	}
+	for i := range keys {
+		if unsealed[i] != keys[i] {
+			t.Errorf("entry %d: unsealed key does not match generated key", i)
+		}
+	}
+}
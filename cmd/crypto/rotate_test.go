@@ -0,0 +1,72 @@
+// MinIO Cloud Storage, (C) 2015, 2016, 2017, 2018 MinIO, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypto
+
+import "testing"
+
+func TestMasterKeyKMSRotateKey(t *testing.T) {
+	kms := newTestMasterKeyKMS()
+	ctx := Context{"bucket": "a", "object": "1"}
+
+	key, sealedKey, err := kms.GenerateKey("old-key", ctx)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	newSealedKey, err := kms.RotateKey("old-key", "new-key", sealedKey, ctx)
+	if err != nil {
+		t.Fatalf("RotateKey: %v", err)
+	}
+
+	rotatedKey, err := kms.UnsealKey("new-key", newSealedKey, ctx)
+	if err != nil {
+		t.Fatalf("UnsealKey after rotation: %v", err)
+	}
+	if rotatedKey != key {
+		t.Fatal("key changed across RotateKey")
+	}
+
+	if _, err := kms.UnsealKey("old-key", newSealedKey, ctx); err == nil {
+		t.Fatal("expected unsealing the rotated key with the old key ID to fail")
+	}
+}
+
+func TestMasterKeyKMSRotateContext(t *testing.T) {
+	kms := newTestMasterKeyKMS()
+	oldCtx := Context{"bucket": "a", "object": "1"}
+	newCtx := Context{"bucket": "a", "object": "1-renamed"}
+
+	key, sealedKey, err := kms.GenerateKey(kms.keyID, oldCtx)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	newSealedKey, err := kms.RotateContext(kms.keyID, sealedKey, oldCtx, newCtx)
+	if err != nil {
+		t.Fatalf("RotateContext: %v", err)
+	}
+
+	rotatedKey, err := kms.UnsealKey(kms.keyID, newSealedKey, newCtx)
+	if err != nil {
+		t.Fatalf("UnsealKey after rotation: %v", err)
+	}
+	if rotatedKey != key {
+		t.Fatal("key changed across RotateContext")
+	}
+
+	if _, err := kms.UnsealKey(kms.keyID, newSealedKey, oldCtx); err == nil {
+		t.Fatal("expected unsealing the rotated key with the old context to fail")
+	}
+}
@@ -49,6 +49,15 @@ type Entry struct {
 	ReqQuery   map[string]string      `json:"requestQuery,omitempty"`
 	ReqHeader  map[string]string      `json:"requestHeader,omitempty"`
 	RespHeader map[string]string      `json:"responseHeader,omitempty"`
+
+	// PrevHash is the Hash of the entry emitted immediately before
+	// this one on the same sink, or empty for the first entry of a
+	// chain. Together with Hash it lets Verify detect deletion or
+	// reordering of entries after the fact.
+	PrevHash string `json:"prevHash,omitempty"`
+	// Hash is SHA-256(canonical-json(entry with Hash=="") || PrevHash),
+	// stamped by Chain.Stamp when the entry is emitted.
+	Hash string `json:"hash,omitempty"`
 }
 
 // ToEntry - constructs an audit entry object.